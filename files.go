@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ghetzel/go-stockutil/log"
+)
+
+// CopyFile copies a single file out of a project's working directory into the top-level tree
+// once sync has cloned/checked out the project, the way Chromium/Fuchsia manifests do.
+type CopyFile struct {
+	Src  string `json:"src,omitempty"  xml:"src,attr,omitempty"`
+	Dest string `json:"dest,omitempty" xml:"dest,attr,omitempty"`
+}
+
+// LinkFile is identical to CopyFile except Dest is created as a symlink to Src instead of a copy.
+type LinkFile struct {
+	Src  string `json:"src,omitempty"  xml:"src,attr,omitempty"`
+	Dest string `json:"dest,omitempty" xml:"dest,attr,omitempty"`
+}
+
+// Apply performs every copyfile/linkfile directive declared on the project. Sources are resolved
+// relative to the project's working directory; destinations are resolved relative to the current
+// working directory (the manifest root).
+func (self ManifestProject) Apply() error {
+	var merr error
+
+	for _, cf := range self.CopyFiles {
+		if err := cf.apply(self.Path); err != nil {
+			merr = log.AppendError(merr, fmt.Errorf("%s: copyfile %s: %v", self.Name, cf.Src, err))
+		}
+	}
+
+	for _, lf := range self.LinkFiles {
+		if err := lf.apply(self.Path); err != nil {
+			merr = log.AppendError(merr, fmt.Errorf("%s: linkfile %s: %v", self.Name, lf.Src, err))
+		}
+	}
+
+	return merr
+}
+
+// apply copies Src (resolved relative to projectPath) to Dest, skipping the write entirely if
+// Dest already has identical content.
+func (self CopyFile) apply(projectPath string) error {
+	src := filepath.Join(projectPath, self.Src)
+
+	if same, _ := sameContents(src, self.Dest); same {
+		return nil
+	}
+
+	in, err := os.Open(src)
+
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+
+	info, err := in.Stat()
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(self.Dest), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(self.Dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+
+	return err
+}
+
+// apply recreates Dest as a symlink to Src (resolved relative to projectPath), leaving it alone
+// if it's already a symlink pointing at the resolved source. The symlink target is written
+// relative to Dest's own directory -- not the process's working directory -- since that's how
+// the OS resolves a relative symlink target.
+func (self LinkFile) apply(projectPath string) error {
+	src := filepath.Join(projectPath, self.Src)
+
+	destDir := filepath.Dir(self.Dest)
+
+	target, err := filepath.Rel(destDir, src)
+
+	if err != nil {
+		target = src
+	}
+
+	if existing, err := os.Readlink(self.Dest); err == nil && existing == target {
+		return nil
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+
+	if err := os.Remove(self.Dest); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Symlink(target, self.Dest)
+}
+
+func sameContents(a string, b string) (bool, error) {
+	ahash, err := fileSHA256(a)
+
+	if err != nil {
+		return false, err
+	}
+
+	bhash, err := fileSHA256(b)
+
+	if err != nil {
+		return false, nil
+	}
+
+	return ahash == bhash, nil
+}
+
+func fileSHA256(filename string) (string, error) {
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return ``, err
+	}
+
+	defer file.Close()
+
+	hash := sha256.New()
+
+	if _, err := io.Copy(hash, file); err != nil {
+		return ``, err
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
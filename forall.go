@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/ghetzel/go-stockutil/log"
+)
+
+// ForallOptions configures Manifest.Forall.
+type ForallOptions struct {
+	Groups   string
+	Jobs     int
+	FailFast bool
+}
+
+// ForallResult captures the outcome of running a forall command in a single project.
+type ForallResult struct {
+	Project  string `json:"project"`
+	Path     string `json:"path"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Forall runs command in the working directory of every project matching opts.Groups (see
+// ManifestProject.MatchesGroups), up to opts.Jobs at a time, streaming interleaved stdout/stderr
+// with a per-line project-name prefix. It returns one ForallResult per project run plus a
+// multi-error aggregating the failures. If opts.FailFast is set, no further projects are started
+// once one fails.
+func (self *Manifest) Forall(command []string, opts ForallOptions) ([]ForallResult, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("no command given")
+	}
+
+	jobs := opts.Jobs
+
+	if jobs <= 0 {
+		jobs = 1
+	}
+
+	projects := self.GetProjects(nil, nil, GetProjectsOpts{Groups: opts.Groups})
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		outmu   sync.Mutex
+		sem     = make(chan struct{}, jobs)
+		results = make([]ForallResult, 0, len(projects))
+		failed  bool
+	)
+
+	for _, project := range projects {
+		project := project
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			mu.Lock()
+			skip := opts.FailFast && failed
+			mu.Unlock()
+
+			if skip {
+				return
+			}
+
+			result := runForallCommand(project, command, &outmu)
+
+			mu.Lock()
+			results = append(results, result)
+
+			if result.ExitCode != 0 {
+				failed = true
+			}
+
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	var merr error
+
+	for _, result := range results {
+		if result.ExitCode != 0 {
+			merr = log.AppendError(merr, fmt.Errorf("%s: %s", result.Project, result.Error))
+		}
+	}
+
+	return results, merr
+}
+
+// runForallCommand runs command in project's working directory with PMAN_* env vars populated,
+// streaming its combined output through streamForallOutput under outmu.
+func runForallCommand(project ManifestProject, command []string, outmu *sync.Mutex) ForallResult {
+	result := ForallResult{Project: project.Name, Path: project.Path}
+
+	branch, _ := GitCurrentBranch(project.Path)
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = project.Path
+	cmd.Env = append(os.Environ(),
+		`PMAN_PROJECT=`+project.Name,
+		`PMAN_PATH=`+project.Path,
+		`PMAN_REMOTE=`+project.Remote,
+		`PMAN_REVISION=`+project.Revision,
+		`PMAN_BRANCH=`+branch,
+	)
+
+	stdout, _ := cmd.StdoutPipe()
+	stderr, _ := cmd.StderrPipe()
+
+	if err := cmd.Start(); err != nil {
+		result.ExitCode = -1
+		result.Error = err.Error()
+		return result
+	}
+
+	var streams sync.WaitGroup
+	streams.Add(2)
+
+	go streamForallOutput(project.Name, stdout, outmu, &streams)
+	go streamForallOutput(project.Name, stderr, outmu, &streams)
+
+	streams.Wait()
+
+	if err := cmd.Wait(); err != nil {
+		result.Error = err.Error()
+
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			result.ExitCode = exitErr.ExitCode()
+		} else {
+			result.ExitCode = -1
+		}
+	}
+
+	return result
+}
+
+// streamForallOutput copies r line-by-line to stdout, prefixing each line with project and
+// serializing writes under outmu so concurrent projects' output doesn't interleave mid-line.
+func streamForallOutput(project string, r io.Reader, outmu *sync.Mutex, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		outmu.Lock()
+		fmt.Printf("%s: %s\n", project, scanner.Text())
+		outmu.Unlock()
+	}
+}
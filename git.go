@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/ghetzel/argonaut"
 	"github.com/ghetzel/go-stockutil/fileutil"
@@ -13,13 +14,16 @@ type git struct {
 	Arguments  []interface{} `argonaut:",positional"`
 }
 
-func GitClone(repository string, destination ...string) error {
+// execGitBackend implements GitBackend by shelling out to the system `git` binary via argonaut.
+type execGitBackend struct{}
+
+func (execGitBackend) Clone(repository string, destination string) error {
 	args := []interface{}{
 		repository,
 	}
 
-	if len(destination) > 0 {
-		args = append(args, destination[0])
+	if destination != `` {
+		args = append(args, destination)
 	}
 
 	if cmd, err := argonaut.Command(&git{
@@ -32,7 +36,7 @@ func GitClone(repository string, destination ...string) error {
 	}
 }
 
-func GitPull(workingDirectory string, ref string) error {
+func (execGitBackend) Pull(workingDirectory string, ref string) error {
 	if fileutil.DirExists(workingDirectory) {
 		if checkout, err := argonaut.Command(&git{
 			Subcommand: `checkout`,
@@ -64,7 +68,23 @@ func GitPull(workingDirectory string, ref string) error {
 	}
 }
 
-func GitBranchTo(workingDirectory string, newBranchName string) error {
+func (execGitBackend) Checkout(workingDirectory string, ref string) error {
+	if fileutil.DirExists(workingDirectory) {
+		if cmd, err := argonaut.Command(&git{
+			Subcommand: `checkout`,
+			Arguments:  []interface{}{ref},
+		}); err == nil {
+			cmd.Dir = workingDirectory
+			return cmd.Run()
+		} else {
+			return err
+		}
+	} else {
+		return fmt.Errorf("no such directory %q", workingDirectory)
+	}
+}
+
+func (execGitBackend) BranchTo(workingDirectory string, newBranchName string) error {
 	if fileutil.DirExists(workingDirectory) {
 		if branch, err := argonaut.Command(&git{
 			Subcommand: `checkout`,
@@ -80,3 +100,33 @@ func GitBranchTo(workingDirectory string, newBranchName string) error {
 		return fmt.Errorf("no such directory %q", workingDirectory)
 	}
 }
+
+func (execGitBackend) CurrentBranch(workingDirectory string) (string, error) {
+	return execGitOutput(workingDirectory, `rev-parse`, `--abbrev-ref`, `HEAD`)
+}
+
+func (execGitBackend) RevParse(workingDirectory string, ref string) (string, error) {
+	return execGitOutput(workingDirectory, `rev-parse`, ref)
+}
+
+// execGitOutput runs the given git subcommand in workingDirectory and returns its trimmed stdout.
+func execGitOutput(workingDirectory string, subcommand string, args ...interface{}) (string, error) {
+	if fileutil.DirExists(workingDirectory) {
+		if cmd, err := argonaut.Command(&git{
+			Subcommand: subcommand,
+			Arguments:  args,
+		}); err == nil {
+			cmd.Dir = workingDirectory
+
+			if output, err := cmd.Output(); err == nil {
+				return strings.TrimSpace(string(output)), nil
+			} else {
+				return ``, err
+			}
+		} else {
+			return ``, err
+		}
+	} else {
+		return ``, fmt.Errorf("no such directory %q", workingDirectory)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+)
+
+// GitBackend abstracts the mechanism pman uses to perform git operations, so that the default
+// exec-based backend (shelling out to the `git` binary) and a native, in-process backend can be
+// swapped in transparently via --git-backend.
+type GitBackend interface {
+	Clone(repository string, destination string) error
+	Pull(workingDirectory string, ref string) error
+	Checkout(workingDirectory string, ref string) error
+	CurrentBranch(workingDirectory string) (string, error)
+	RevParse(workingDirectory string, ref string) (string, error)
+	BranchTo(workingDirectory string, newBranchName string) error
+}
+
+// ActiveGitBackend is the GitBackend that the package-level Git* helpers dispatch to. It defaults
+// to the exec-based backend; SelectGitBackend switches it based on the --git-backend flag.
+var ActiveGitBackend GitBackend = execGitBackend{}
+
+// SelectGitBackend sets ActiveGitBackend by name ("exec" or "native"), defaulting to "exec" when
+// name is empty.
+func SelectGitBackend(name string) error {
+	switch sliceutil.OrString(name, `exec`) {
+	case `exec`:
+		ActiveGitBackend = execGitBackend{}
+	case `native`:
+		ActiveGitBackend = nativeGitBackend{}
+	default:
+		return fmt.Errorf("unknown git backend %q", name)
+	}
+
+	return nil
+}
+
+func GitClone(repository string, destination ...string) error {
+	var dest string
+
+	if len(destination) > 0 {
+		dest = destination[0]
+	}
+
+	return ActiveGitBackend.Clone(repository, dest)
+}
+
+func GitPull(workingDirectory string, ref string) error {
+	return ActiveGitBackend.Pull(workingDirectory, ref)
+}
+
+func GitBranchTo(workingDirectory string, newBranchName string) error {
+	return ActiveGitBackend.BranchTo(workingDirectory, newBranchName)
+}
+
+// GitCurrentBranch returns the name of the branch currently checked out in workingDirectory.
+func GitCurrentBranch(workingDirectory string) (string, error) {
+	return ActiveGitBackend.CurrentBranch(workingDirectory)
+}
+
+// GitRevParse resolves ref (e.g. "HEAD") to its full commit SHA in workingDirectory.
+func GitRevParse(workingDirectory string, ref string) (string, error) {
+	return ActiveGitBackend.RevParse(workingDirectory, ref)
+}
+
+// GitCheckoutDetached checks out ref (typically a pinned commit SHA) without attempting to pull
+// it, leaving workingDirectory in a detached-HEAD state at that exact commit.
+func GitCheckoutDetached(workingDirectory string, ref string) error {
+	return ActiveGitBackend.Checkout(workingDirectory, ref)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// nativeGitBackend implements GitBackend in-process using go-git, without depending on a `git`
+// binary being present. HTTP(S) remotes authenticate via a PMAN_GIT_TOKEN token (sent as the
+// HTTP basic auth password) or ~/.netrc; SSH remotes authenticate via the local ssh-agent.
+type nativeGitBackend struct{}
+
+func (nativeGitBackend) Clone(repository string, destination string) error {
+	_, err := gogit.PlainClone(destination, false, &gogit.CloneOptions{
+		URL:  repository,
+		Auth: nativeGitAuth(repository),
+	})
+
+	return err
+}
+
+func (nativeGitBackend) Pull(workingDirectory string, ref string) error {
+	repo, err := gogit.PlainOpen(workingDirectory)
+
+	if err != nil {
+		return fmt.Errorf("no such directory %q", workingDirectory)
+	}
+
+	if err := nativeGitCheckout(repo, ref); err != nil {
+		return fmt.Errorf("checkout failed: %v", err)
+	}
+
+	if ref == `` {
+		return nil
+	}
+
+	tree, err := repo.Worktree()
+
+	if err != nil {
+		return fmt.Errorf("pull failed: %v", err)
+	}
+
+	remoteURL, err := nativeGitRemoteURL(repo)
+
+	if err != nil {
+		return fmt.Errorf("pull failed: %v", err)
+	}
+
+	if err := tree.Pull(&gogit.PullOptions{
+		RemoteName: `origin`,
+		Auth:       nativeGitAuth(remoteURL),
+	}); err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return fmt.Errorf("pull failed: %v", err)
+	}
+
+	return nil
+}
+
+func (nativeGitBackend) Checkout(workingDirectory string, ref string) error {
+	repo, err := gogit.PlainOpen(workingDirectory)
+
+	if err != nil {
+		return fmt.Errorf("no such directory %q", workingDirectory)
+	}
+
+	return nativeGitCheckout(repo, ref)
+}
+
+func (nativeGitBackend) BranchTo(workingDirectory string, newBranchName string) error {
+	repo, err := gogit.PlainOpen(workingDirectory)
+
+	if err != nil {
+		return fmt.Errorf("no such directory %q", workingDirectory)
+	}
+
+	tree, err := repo.Worktree()
+
+	if err != nil {
+		return err
+	}
+
+	return tree.Checkout(&gogit.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(newBranchName),
+		Create: true,
+	})
+}
+
+func (nativeGitBackend) CurrentBranch(workingDirectory string) (string, error) {
+	repo, err := gogit.PlainOpen(workingDirectory)
+
+	if err != nil {
+		return ``, fmt.Errorf("no such directory %q", workingDirectory)
+	}
+
+	head, err := repo.Head()
+
+	if err != nil {
+		return ``, err
+	}
+
+	return head.Name().Short(), nil
+}
+
+func (nativeGitBackend) RevParse(workingDirectory string, ref string) (string, error) {
+	repo, err := gogit.PlainOpen(workingDirectory)
+
+	if err != nil {
+		return ``, fmt.Errorf("no such directory %q", workingDirectory)
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+
+	if err != nil {
+		return ``, err
+	}
+
+	return hash.String(), nil
+}
+
+func nativeGitCheckout(repo *gogit.Repository, ref string) error {
+	if ref == `` {
+		return nil
+	}
+
+	tree, err := repo.Worktree()
+
+	if err != nil {
+		return err
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+
+	if err != nil {
+		return err
+	}
+
+	return tree.Checkout(&gogit.CheckoutOptions{Hash: *hash})
+}
+
+func nativeGitRemoteURL(repo *gogit.Repository) (string, error) {
+	remote, err := repo.Remote(`origin`)
+
+	if err != nil {
+		return ``, err
+	}
+
+	if urls := remote.Config().URLs; len(urls) > 0 {
+		return urls[0], nil
+	}
+
+	return ``, fmt.Errorf("remote %q has no URL", `origin`)
+}
+
+// nativeGitAuth picks an authentication method for repository: SSH-agent auth for ssh:// and
+// scp-like "git@host:..." URLs, otherwise HTTP basic auth sourced from PMAN_GIT_TOKEN or
+// ~/.netrc. Returns nil (anonymous auth) if none of those apply.
+func nativeGitAuth(repository string) transport.AuthMethod {
+	if strings.HasPrefix(repository, `git@`) || strings.HasPrefix(repository, `ssh://`) {
+		if auth, err := gitssh.NewSSHAgentAuth(`git`); err == nil {
+			return auth
+		}
+
+		return nil
+	}
+
+	if token := os.Getenv(`PMAN_GIT_TOKEN`); token != `` {
+		return &githttp.BasicAuth{
+			Username: `pman`,
+			Password: token,
+		}
+	}
+
+	if login, password, ok := netrcCredentials(repository); ok {
+		return &githttp.BasicAuth{
+			Username: login,
+			Password: password,
+		}
+	}
+
+	return nil
+}
+
+// netrcCredentials looks up a login/password pair for repository's host in ~/.netrc (or the file
+// named by $NETRC), returning ok=false if no matching machine entry is found.
+func netrcCredentials(repository string) (string, string, bool) {
+	parsed, err := url.Parse(repository)
+
+	if err != nil || parsed.Hostname() == `` {
+		return ``, ``, false
+	}
+
+	netrcPath := os.Getenv(`NETRC`)
+
+	if netrcPath == `` {
+		home, err := os.UserHomeDir()
+
+		if err != nil {
+			return ``, ``, false
+		}
+
+		netrcPath = filepath.Join(home, `.netrc`)
+	}
+
+	data, err := os.ReadFile(netrcPath)
+
+	if err != nil {
+		return ``, ``, false
+	}
+
+	fields := strings.Fields(string(data))
+	var machine, login, password string
+
+	for i, field := range fields {
+		if i+1 >= len(fields) {
+			break
+		}
+
+		switch field {
+		case `machine`:
+			machine = fields[i+1]
+		case `login`:
+			if machine == parsed.Hostname() {
+				login = fields[i+1]
+			}
+		case `password`:
+			if machine == parsed.Hostname() {
+				password = fields[i+1]
+			}
+		}
+	}
+
+	if login != `` && password != `` {
+		return login, password, true
+	}
+
+	return ``, ``, false
+}
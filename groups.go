@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/ghetzel/go-stockutil/sliceutil"
+)
+
+// GetProjectsOpts controls how Manifest.GetProjects selects and filters projects.
+type GetProjectsOpts struct {
+	// Groups is a repo-style group filter expression (see ManifestProject.MatchesGroups). An
+	// empty value matches the implicit "default" group, i.e. every project not tagged
+	// "notdefault".
+	Groups string
+}
+
+// MatchesGroups reports whether this project is selected by expr, a comma/space-separated list of
+// group filter tokens using the same syntax as Google's `repo` tool: a bare "name" (or "+name")
+// includes projects tagged with that group, "-name" excludes them, "default" matches any project
+// not tagged "notdefault", and "all" matches every project. An empty expr behaves as "all".
+func (self ManifestProject) MatchesGroups(expr string) bool {
+	expr = sliceutil.OrString(expr, `all`)
+
+	groupNames := splitGroupExpr(self.GroupNames)
+	groupNames = append(groupNames, `all`)
+
+	if !sliceutil.ContainsString(groupNames, `notdefault`) {
+		groupNames = append(groupNames, `default`)
+	}
+
+	var matched bool
+
+	for _, token := range splitGroupExpr(expr) {
+		switch {
+		case strings.HasPrefix(token, `-`):
+			if sliceutil.ContainsString(groupNames, strings.TrimPrefix(token, `-`)) {
+				matched = false
+			}
+		case strings.HasPrefix(token, `+`):
+			if sliceutil.ContainsString(groupNames, strings.TrimPrefix(token, `+`)) {
+				matched = true
+			}
+		default:
+			if sliceutil.ContainsString(groupNames, token) {
+				matched = true
+			}
+		}
+	}
+
+	return matched
+}
+
+// SkipInclude reports whether this project should be excluded from a Manifest.GetProjects result
+// given groups, a repo-style group filter expression (see MatchesGroups). An empty groups matches
+// the implicit "default" group.
+func (self ManifestProject) SkipInclude(groups string) bool {
+	return !self.MatchesGroups(sliceutil.OrString(groups, `default`))
+}
+
+func splitGroupExpr(expr string) []string {
+	return sliceutil.CompactString(strings.FieldsFunc(expr, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	}))
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestMatchesGroups(t *testing.T) {
+	tests := []struct {
+		name       string
+		groupNames string
+		expr       string
+		want       bool
+	}{
+		{`empty expr matches default project`, ``, ``, true},
+		{`empty expr behaves as all, matching notdefault too`, `notdefault`, ``, true},
+		{`all matches everything`, `notdefault`, `all`, true},
+		{`bare token includes tagged group`, `tools`, `tools`, true},
+		{`bare token excludes untagged group`, `docs`, `tools`, false},
+		{`plus token is a synonym for bare include`, `tools`, `default,+tools`, true},
+		{`minus token excludes a tagged group`, `docs`, `default,-docs`, false},
+		{`minus takes effect after an earlier include`, `docs,tools`, `tools,-docs`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			project := ManifestProject{GroupNames: test.groupNames}
+
+			if got := project.MatchesGroups(test.expr); got != test.want {
+				t.Errorf("MatchesGroups(%q) with groups %q = %v, want %v", test.expr, test.groupNames, got, test.want)
+			}
+		})
+	}
+}
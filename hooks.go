@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ghetzel/go-stockutil/log"
+)
+
+// DefaultHookTimeout is how long a hook is allowed to run before being killed, if the hook itself
+// doesn't specify a Timeout.
+var DefaultHookTimeout = 5 * time.Minute
+
+// HookEvent identifies the point in the sync/checkout lifecycle at which a hook runs.
+type HookEvent string
+
+const (
+	HookPreSync      HookEvent = `pre-sync`
+	HookPostSync     HookEvent = `post-sync`
+	HookPreCheckout  HookEvent = `pre-checkout`
+	HookPostCheckout HookEvent = `post-checkout`
+)
+
+// ManifestHook describes a command to run at a given point in the sync or checkout lifecycle,
+// either manifest-wide (optionally scoped to a subset of projects via Project) or attached
+// directly to a single project.
+type ManifestHook struct {
+	Name    string `json:"name,omitempty"    xml:"name,attr,omitempty"`
+	On      string `json:"on,omitempty"      xml:"on,attr,omitempty"`
+	Project string `json:"project,omitempty" xml:"project,attr,omitempty"`
+	Cmd     string `json:"cmd,omitempty"     xml:"cmd,attr,omitempty"`
+	Timeout string `json:"timeout,omitempty" xml:"timeout,attr,omitempty"`
+}
+
+// MatchesProject reports whether this hook applies to the given project name. An empty or "*"
+// Project matches every project; a trailing "*" matches by prefix; anything else matches exactly.
+func (self ManifestHook) MatchesProject(name string) bool {
+	switch {
+	case self.Project == ``, self.Project == `*`:
+		return true
+	case strings.HasSuffix(self.Project, `*`):
+		return strings.HasPrefix(name, strings.TrimSuffix(self.Project, `*`))
+	default:
+		return self.Project == name
+	}
+}
+
+// timeout returns the hook's configured Timeout, falling back to DefaultHookTimeout if unset or
+// unparseable.
+func (self ManifestHook) timeout() time.Duration {
+	if self.Timeout != `` {
+		if d, err := time.ParseDuration(self.Timeout); err == nil {
+			return d
+		}
+	}
+
+	return DefaultHookTimeout
+}
+
+// Run executes the hook's Cmd in project's working directory via the shell, populating
+// PMAN_PROJECT, PMAN_REVISION, PMAN_REMOTE, and PMAN_PATH, and killing the command if it
+// outlives the hook's timeout.
+func (self ManifestHook) Run(project ManifestProject) error {
+	ctx, cancel := context.WithTimeout(context.Background(), self.timeout())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, `sh`, `-c`, self.Cmd)
+	cmd.Dir = project.Path
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		`PMAN_PROJECT=`+project.Name,
+		`PMAN_REVISION=`+project.Revision,
+		`PMAN_REMOTE=`+project.Remote,
+		`PMAN_PATH=`+project.Path,
+	)
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("hook %q: timed out after %s", self.Name, self.timeout())
+		}
+
+		return fmt.Errorf("hook %q: %v", self.Name, err)
+	}
+
+	return nil
+}
+
+// runHooks runs every hook bound to event for project -- both manifest-level hooks that match the
+// project and the project's own hooks -- aggregating any failures into a single multi-error.
+func (self *Manifest) runHooks(event HookEvent, project ManifestProject) error {
+	var merr error
+
+	for _, hook := range self.Hooks {
+		if hook.On == string(event) && hook.MatchesProject(project.Name) {
+			if err := hook.Run(project); err != nil {
+				merr = log.AppendError(merr, err)
+			}
+		}
+	}
+
+	for _, hook := range project.Hooks {
+		if hook.On == string(event) {
+			if err := hook.Run(project); err != nil {
+				merr = log.AppendError(merr, err)
+			}
+		}
+	}
+
+	return merr
+}
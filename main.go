@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/ghetzel/cli"
 	"github.com/ghetzel/go-stockutil/fileutil"
 	"github.com/ghetzel/go-stockutil/log"
+	"github.com/ghetzel/go-stockutil/sliceutil"
 	"github.com/ghetzel/go-stockutil/typeutil"
 )
 
@@ -26,11 +29,23 @@ func main() {
 			Value:  `info`,
 			EnvVar: `LOGLEVEL`,
 		},
+		cli.IntFlag{
+			Name:   `jobs, j`,
+			Usage:  `Number of concurrent per-project operations to run when syncing, checking out, or running forall.`,
+			Value:  runtime.NumCPU(),
+			EnvVar: `PMAN_JOBS`,
+		},
+		cli.StringFlag{
+			Name:   `git-backend`,
+			Usage:  `Git backend to use: "exec" (shell out to the git binary) or "native" (in-process go-git).`,
+			Value:  `exec`,
+			EnvVar: `PMAN_GIT_BACKEND`,
+		},
 	}
 
 	app.Before = func(c *cli.Context) error {
 		log.SetLevelString(c.String(`log-level`))
-		return nil
+		return SelectGitBackend(c.String(`git-backend`))
 	}
 
 	app.Commands = []cli.Command{
@@ -52,9 +67,13 @@ func main() {
 					Name:  `force, f`,
 					Usage: `Force overwriting existing working directories.`,
 				},
+				cli.StringFlag{
+					Name:  `groups`,
+					Usage: `Repo-style group filter expression (e.g. "default,-docs,+tools") selecting which projects to sync.`,
+				},
 			},
 			Action: func(c *cli.Context) {
-				if err := loadManifest(c).Sync(c.Bool(`force`)); err != nil {
+				if err := loadManifest(c).Sync(c.Bool(`force`), c.GlobalInt(`jobs`), c.String(`groups`)); err != nil {
 					log.Fatal(err)
 				}
 			},
@@ -67,11 +86,15 @@ func main() {
 					Name:  `force, f`,
 					Usage: `Force overwriting existing working directories.`,
 				},
+				cli.StringFlag{
+					Name:  `groups`,
+					Usage: `Repo-style group filter expression (e.g. "default,-docs,+tools") selecting which projects to checkout.`,
+				},
 			},
 			Action: func(c *cli.Context) {
 				if c.NArg() > 0 {
 					branch := c.Args().First()
-					if err := loadManifest(c).Checkout(branch, c.Bool(`force`)); err != nil {
+					if err := loadManifest(c).Checkout(branch, c.Bool(`force`), c.GlobalInt(`jobs`), c.String(`groups`)); err != nil {
 						log.Fatal(err)
 					}
 				} else {
@@ -81,12 +104,18 @@ func main() {
 		}, {
 			Name:  `status`,
 			Usage: `Get the current status of each repository in the current project.`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `groups`,
+					Usage: `Repo-style group filter expression (e.g. "default,-docs,+tools") selecting which projects to show.`,
+				},
+			},
 			Action: func(c *cli.Context) {
 				var statuses []map[string]interface{}
 
 				manifest := loadManifest(c)
 
-				for _, project := range manifest.GetProjects(nil, nil) {
+				for _, project := range manifest.GetProjects(nil, nil, GetProjectsOpts{Groups: c.String(`groups`)}) {
 					status := make(map[string]interface{})
 
 					status[`project`] = project.Name
@@ -126,13 +155,115 @@ func main() {
 		}, {
 			Name:  `dump-projects`,
 			Usage: `Dump the evaluated project manifest.`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  `groups`,
+					Usage: `Repo-style group filter expression (e.g. "default,-docs,+tools") selecting which projects to dump.`,
+				},
+			},
 			Action: func(c *cli.Context) {
-				if out, err := json.MarshalIndent(loadManifest(c).GetProjects(nil, nil), ``, `  `); err == nil {
+				projects := loadManifest(c).GetProjects(nil, nil, GetProjectsOpts{Groups: c.String(`groups`)})
+
+				if out, err := json.MarshalIndent(projects, ``, `  `); err == nil {
 					fmt.Println(string(out))
 				} else {
 					log.Fatal(err)
 				}
 			},
+		}, {
+			Name:      `snapshot`,
+			Usage:     `Export a pinned snapshot of the current project manifest for reproducible builds.`,
+			ArgsUsage: `MANIFEST_XML SOURCE_MANIFEST_JSON`,
+			Action: func(c *cli.Context) {
+				manifestFile := sliceutil.OrString(c.Args().Get(0), `snapshot.xml`)
+				sourceManifestFile := sliceutil.OrString(c.Args().Get(1), `snapshot-source-manifest.json`)
+
+				pinned, entries, err := loadManifest(c).Snapshot()
+
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				if err := WriteSnapshot(manifestFile, sourceManifestFile, pinned, entries); err != nil {
+					log.Fatal(err)
+				}
+
+				log.Noticef("Wrote pinned manifest to %s and source manifest to %s", manifestFile, sourceManifestFile)
+			},
+		}, {
+			Name:      `restore`,
+			Usage:     `Detach every project in a pinned snapshot manifest at its recorded revision.`,
+			ArgsUsage: `SNAPSHOT_XML`,
+			Action: func(c *cli.Context) {
+				if c.NArg() > 0 {
+					manifest, err := LoadManifest(c.Args().First())
+
+					if err != nil {
+						log.Fatal(err)
+					}
+
+					if err := manifest.Restore(); err != nil {
+						log.Fatal(err)
+					}
+				} else {
+					cli.ShowCommandHelp(c, `restore`)
+				}
+			},
+		}, {
+			Name:      `forall`,
+			Usage:     `Run a command in the working directory of every project.`,
+			ArgsUsage: `[--] COMMAND [ARG...]`,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  `c`,
+					Usage: `Run COMMAND through the shell instead of executing it directly.`,
+				},
+				cli.StringFlag{
+					Name:  `groups`,
+					Usage: `Repo-style group filter expression (e.g. "default,-docs,+tools") selecting which projects to run in.`,
+				},
+				cli.BoolFlag{
+					Name:  `fail-fast`,
+					Usage: `Stop scheduling new projects as soon as one command fails.`,
+				},
+				cli.StringFlag{
+					Name:  `format`,
+					Usage: `Output format: "text" (default) or "json" for a machine-readable summary.`,
+					Value: `text`,
+				},
+			},
+			Action: func(c *cli.Context) {
+				args := []string(c.Args())
+
+				if len(args) == 0 {
+					cli.ShowCommandHelp(c, `forall`)
+					return
+				}
+
+				command := args
+
+				if c.Bool(`c`) {
+					command = []string{`sh`, `-c`, strings.Join(args, ` `)}
+				}
+
+				results, runErr := loadManifest(c).Forall(command, ForallOptions{
+					Groups:   c.String(`groups`),
+					Jobs:     c.GlobalInt(`jobs`),
+					FailFast: c.Bool(`fail-fast`),
+				})
+
+				if c.String(`format`) == `json` {
+					if out, err := json.MarshalIndent(results, ``, `  `); err == nil {
+						fmt.Println(string(out))
+					} else {
+						log.Fatal(err)
+					}
+				}
+
+				if runErr != nil {
+					log.Fatal(runErr)
+				}
+			},
 		},
 	}
 
@@ -6,7 +6,9 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/ghetzel/go-stockutil/fileutil"
 	"github.com/ghetzel/go-stockutil/log"
@@ -18,6 +20,7 @@ var DefaultLocalManifestFile = `pman.xml`
 var ManifestRepoWorkingDirectory = `.repo/manifest`
 var ManifestRepoRef = sliceutil.OrString(os.Getenv(`PMAN_MANIFEST_REPO_BRANCH`), `master`)
 var ManifestRepoFilename = sliceutil.OrString(os.Getenv(`PMAN_MANIFEST_REPO_FILENAME`), `default.xml`)
+var ManifestImportsWorkingDirectory = `.repo/manifest-imports`
 
 type ManifestBranch struct {
 	Name     string `json:"name,omitempty"     xml:"name,attr,omitempty"`
@@ -43,6 +46,20 @@ type ManifestConfig struct {
 	Branches []ManifestBranch `json:"branches,omitempty" xml:"branch,omitempty"`
 }
 
+// ManifestInclude pulls another manifest file, resolved relative to the including manifest's
+// directory, into the current one.
+type ManifestInclude struct {
+	Name string `json:"name,omitempty" xml:"name,attr,omitempty"`
+}
+
+// ManifestImport clones or pulls a remote manifest repository and pulls a named XML file out of
+// it, the way <include> does for a local file.
+type ManifestImport struct {
+	Name     string `json:"name,omitempty"     xml:"name,attr,omitempty"`
+	Remote   string `json:"remote,omitempty"   xml:"remote,attr,omitempty"`
+	Revision string `json:"revision,omitempty" xml:"revision,attr,omitempty"`
+}
+
 type ManifestRemote struct {
 	Name            string `json:"name,omitempty"   xml:"name,attr,omitempty"`
 	Fetch           string `json:"fetch,omitempty"  xml:"fetch,attr,omitempty"`
@@ -57,6 +74,9 @@ type ManifestProject struct {
 	Projects   []ManifestProject `json:"project,omitempty"  xml:"project,omitempty"`
 	Revision   string            `json:"revision,omitempty" xml:"revision,attr,omitempty"`
 	GroupNames string            `json:"groups,omitempty"   xml:"groups,attr,omitempty"`
+	Hooks      []ManifestHook    `json:"hooks,omitempty"    xml:"hook,omitempty"`
+	CopyFiles  []CopyFile        `json:"copyfiles,omitempty" xml:"copyfile,omitempty"`
+	LinkFiles  []LinkFile        `json:"linkfiles,omitempty" xml:"linkfile,omitempty"`
 }
 
 func (self ManifestProject) Clone(force bool) error {
@@ -99,15 +119,14 @@ func (self ManifestProject) Fork(revision string, branchFrom string) error {
 	return GitBranchTo(self.Path, revision)
 }
 
-func (self ManifestProject) SkipInclude() bool {
-	return sliceutil.ContainsString(strings.Split(self.GroupNames, ` `), `notdefault`)
-}
-
 type Manifest struct {
 	Remotes  []ManifestRemote  `json:"remotes,omitempty"  xml:"remote,omitempty"`
 	Default  *ManifestProject  `json:"default,omitempty"  xml:"default,omitempty"`
 	Projects []ManifestProject `json:"projects,omitempty" xml:"project,omitempty"`
 	Config   *ManifestConfig   `json:"config,omitempty"   xml:"config,omitempty"`
+	Includes []ManifestInclude `json:"includes,omitempty" xml:"include,omitempty"`
+	Imports  []ManifestImport  `json:"imports,omitempty"  xml:"import,omitempty"`
+	Hooks    []ManifestHook    `json:"hooks,omitempty"    xml:"hooks>hook,omitempty"`
 }
 
 func (self *Manifest) GetRemote(name string) *ManifestRemote {
@@ -120,36 +139,128 @@ func (self *Manifest) GetRemote(name string) *ManifestRemote {
 	return nil
 }
 
-// Sync all projects in this manifest.
-func (self *Manifest) Sync(force bool) error {
-	var merr error
+// Sync all projects in this manifest matching groups, running up to jobs operations
+// concurrently.
+func (self *Manifest) Sync(force bool, jobs int, groups string) error {
+	return self.forEachProject(jobs, groups, func(project ManifestProject, logmu *sync.Mutex) error {
+		if err := self.runHooks(HookPreSync, project); err != nil {
+			return err
+		}
+
+		if err := project.Clone(force); err != nil {
+			return fmt.Errorf("Error syncing %v: %v", project.Name, err)
+		}
 
-	for _, project := range self.GetProjects(nil, nil) {
-		if err := project.Clone(force); err == nil {
-			log.Infof("Synced %v", project.Name)
-		} else {
-			merr = log.AppendError(merr, fmt.Errorf("Error syncing %v: %v", project.Name, err))
+		if err := project.Apply(); err != nil {
+			return err
 		}
-	}
 
-	return merr
+		if err := self.runHooks(HookPostSync, project); err != nil {
+			return err
+		}
+
+		logmu.Lock()
+		log.Infof("Synced %v", project.Name)
+		logmu.Unlock()
+		return nil
+	})
 }
 
-func (self *Manifest) Checkout(branch string, force bool) error {
-	var merr error
+// Checkout all projects in this manifest matching groups to the given branch, running up to
+// jobs operations concurrently.
+func (self *Manifest) Checkout(branch string, force bool, jobs int, groups string) error {
+	return self.forEachProject(jobs, groups, func(project ManifestProject, logmu *sync.Mutex) error {
+		if err := self.runHooks(HookPreCheckout, project); err != nil {
+			return err
+		}
+
+		nowOnBranch, err := project.Checkout(branch, true)
 
-	for _, project := range self.GetProjects(nil, nil) {
-		if nowOnBranch, err := project.Checkout(branch, true); err == nil {
-			log.Debugf("Project %s now on branch %s", project.Name, nowOnBranch)
-		} else {
-			merr = log.AppendError(merr, fmt.Errorf("Error checking out %s: %v", project.Name, err))
+		if err != nil {
+			return fmt.Errorf("Error checking out %s: %v", project.Name, err)
+		}
+
+		if err := self.runHooks(HookPostCheckout, project); err != nil {
+			return err
 		}
+
+		logmu.Lock()
+		log.Debugf("Project %s now on branch %s", project.Name, nowOnBranch)
+		logmu.Unlock()
+		return nil
+	})
+}
+
+// forEachProject runs fn for every project matching groups using a worker pool bounded by jobs
+// (falling back to runtime.NumCPU() if jobs <= 0), additionally honoring each remote's
+// SyncConcurrency as a per-remote upper bound so a single remote isn't hammered by a wide-open
+// job count. fn is handed a shared mutex to serialize its own progress logging; errors returned
+// by fn are aggregated into a single multi-error via log.AppendError.
+func (self *Manifest) forEachProject(jobs int, groups string, fn func(project ManifestProject, logmu *sync.Mutex) error) error {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
 	}
 
+	var (
+		wg        sync.WaitGroup
+		logmu     sync.Mutex
+		merr      error
+		sem       = make(chan struct{}, jobs)
+		remoteSem = make(map[string]chan struct{})
+	)
+
+	for _, remote := range self.Remotes {
+		if remote.SyncConcurrency > 0 {
+			remoteSem[remote.Name] = make(chan struct{}, remote.SyncConcurrency)
+		}
+	}
+
+	for _, project := range self.GetProjects(nil, nil, GetProjectsOpts{Groups: groups}) {
+		project := project
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			remoteName := project.Remote
+
+			// projects that don't name a remote are fetched from the first (default) one
+			if remoteName == `` && len(self.Remotes) > 0 {
+				remoteName = self.Remotes[0].Name
+			}
+
+			if rsem, ok := remoteSem[remoteName]; ok {
+				rsem <- struct{}{}
+				defer func() { <-rsem }()
+			}
+
+			if err := fn(project, &logmu); err != nil {
+				logmu.Lock()
+				merr = log.AppendError(merr, err)
+				logmu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
 	return merr
 }
 
-func (self *Manifest) GetProjects(from []ManifestProject, parent *ManifestProject) []ManifestProject {
+// GetProjects flattens the manifest's project tree, starting at from (the whole manifest if nil)
+// with parent as the inherited default project (self.Default if nil), optionally filtered by a
+// GetProjectsOpts.Groups expression (see ManifestProject.MatchesGroups; the implicit "default"
+// group if no opts are given).
+func (self *Manifest) GetProjects(from []ManifestProject, parent *ManifestProject, opts ...GetProjectsOpts) []ManifestProject {
+	var opt GetProjectsOpts
+
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
 	projects := make([]ManifestProject, 0)
 
 	var root []ManifestProject
@@ -228,11 +339,11 @@ func (self *Manifest) GetProjects(from []ManifestProject, parent *ManifestProjec
 		if err := maputil.StructFromMap(pmap.MapNative(), &project); err == nil {
 			// depth-first recursion to handle subprojects
 			if subprojects := project.Projects; len(subprojects) > 0 {
-				projects = append(projects, self.GetProjects(subprojects, &project)...)
+				projects = append(projects, self.GetProjects(subprojects, &project, opt)...)
 			}
 
-			// don't add this project if it's in the "notdefault" group
-			if !pdef.SkipInclude() {
+			// don't add this project if it's excluded by the group filter
+			if !pdef.SkipInclude(opt.Groups) {
 				// now that the values are finalized, do some last minute processing
 
 				// expand ~ in path
@@ -271,20 +382,193 @@ func (self *Manifest) ColorForBranch(branch string) string {
 	return `reset`
 }
 
+// LoadManifest reads and parses the manifest at filename, recursively resolving any <include> and
+// <import> directives it contains. Cycles (an include or import that, directly or transitively,
+// pulls in something already being resolved) are rejected with an error rather than looping
+// forever.
 func LoadManifest(filename string) (*Manifest, error) {
-	if file, err := os.Open(filename); err == nil {
-		defer file.Close()
+	return resolveManifest(filename, make(map[string]bool))
+}
 
-		var manifest Manifest
+// resolveManifest does the work of LoadManifest, tracking the includes and imports currently on the
+// recursion stack in visited so that cycles can be detected. Includes are keyed by absolute path;
+// imports are keyed by (remote, revision, name) since the same remote manifest repo may be
+// imported under different filenames. Entries are removed once their subtree finishes resolving,
+// so a diamond-shaped (non-cyclic) include graph -- the same manifest reached via two different
+// paths -- is not mistaken for a cycle.
+func resolveManifest(filename string, visited map[string]bool) (*Manifest, error) {
+	absFilename, err := filepath.Abs(filename)
 
-		if err := xml.NewDecoder(file).Decode(&manifest); err == nil {
-			return &manifest, nil
-		} else {
+	if err != nil {
+		return nil, err
+	}
+
+	if visited[absFilename] {
+		return nil, fmt.Errorf("include cycle detected at %q", filename)
+	}
+
+	visited[absFilename] = true
+	defer delete(visited, absFilename)
+
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var manifest Manifest
+
+	if err := xml.NewDecoder(file).Decode(&manifest); err != nil {
+		return nil, err
+	}
+
+	manifestDir := filepath.Dir(filename)
+
+	for _, include := range manifest.Includes {
+		includePath := include.Name
+
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(manifestDir, includePath)
+		}
+
+		included, err := resolveManifest(includePath, visited)
+
+		if err != nil {
+			return nil, fmt.Errorf("include %q: %v", include.Name, err)
+		}
+
+		manifest = mergeManifests(*included, manifest)
+	}
+
+	for _, imprt := range manifest.Imports {
+		imported, err := resolveManifestImport(&manifest, imprt, visited)
+
+		if err != nil {
 			return nil, err
 		}
+
+		manifest = mergeManifests(*imported, manifest)
+	}
+
+	return &manifest, nil
+}
+
+// resolveManifestImport clones/pulls the remote manifest repo referenced by imprt and loads the
+// named XML file out of it, tracking imprt on the recursion stack in visited for the duration of
+// the call so that cycles are caught without rejecting a diamond-shaped (non-cyclic) import graph.
+func resolveManifestImport(manifest *Manifest, imprt ManifestImport, visited map[string]bool) (*Manifest, error) {
+	importKey := fmt.Sprintf("import:%s@%s/%s", imprt.Remote, imprt.Revision, imprt.Name)
+
+	if visited[importKey] {
+		return nil, fmt.Errorf("import cycle detected at %q", importKey)
+	}
+
+	visited[importKey] = true
+	defer delete(visited, importKey)
+
+	remote := manifest.GetRemote(imprt.Remote)
+
+	if remote == nil {
+		return nil, fmt.Errorf("import %q: remote %q does not exist", imprt.Name, imprt.Remote)
+	}
+
+	importDir := filepath.Join(ManifestImportsWorkingDirectory, imprt.Remote)
+	revision := sliceutil.OrString(imprt.Revision, ManifestRepoRef)
+
+	if fileutil.DirExists(filepath.Join(importDir, `.git`)) {
+		if err := GitPull(importDir, revision); err != nil {
+			return nil, fmt.Errorf("import %q: failed to update manifest repo: %v", imprt.Name, err)
+		}
 	} else {
-		return nil, err
+		if err := GitClone(remote.Fetch, importDir); err != nil {
+			return nil, fmt.Errorf("import %q: failed to clone manifest repo: %v", imprt.Name, err)
+		}
+
+		if imprt.Revision != `` {
+			if err := GitPull(importDir, imprt.Revision); err != nil {
+				return nil, fmt.Errorf("import %q: %v", imprt.Name, err)
+			}
+		}
+	}
+
+	imported, err := resolveManifest(filepath.Join(importDir, imprt.Name), visited)
+
+	if err != nil {
+		return nil, fmt.Errorf("import %q: %v", imprt.Name, err)
+	}
+
+	return imported, nil
+}
+
+// mergeManifests combines a parent manifest (the target of an <include> or <import>) with the
+// child manifest that referenced it. The child's Remotes and Projects take precedence over
+// parent entries of the same name, and the child's Default/Config replace the parent's wholesale
+// when set. Hooks from both are kept, the parent's first. The child's own Includes and Imports
+// are kept; the parent's, already resolved into its projects, are not.
+func mergeManifests(parent Manifest, child Manifest) Manifest {
+	merged := Manifest{
+		Remotes:  mergeManifestRemotes(parent.Remotes, child.Remotes),
+		Projects: mergeManifestProjects(parent.Projects, child.Projects),
+		Default:  child.Default,
+		Config:   child.Config,
+		Includes: child.Includes,
+		Imports:  child.Imports,
+		Hooks:    append(append([]ManifestHook{}, parent.Hooks...), child.Hooks...),
 	}
+
+	if merged.Default == nil {
+		merged.Default = parent.Default
+	}
+
+	if merged.Config == nil {
+		merged.Config = parent.Config
+	}
+
+	return merged
+}
+
+func mergeManifestRemotes(parent []ManifestRemote, child []ManifestRemote) []ManifestRemote {
+	merged := make([]ManifestRemote, 0, len(parent)+len(child))
+	indexByName := make(map[string]int)
+
+	for _, remote := range parent {
+		indexByName[remote.Name] = len(merged)
+		merged = append(merged, remote)
+	}
+
+	for _, remote := range child {
+		if i, ok := indexByName[remote.Name]; ok && remote.Name != `` {
+			merged[i] = remote
+		} else {
+			indexByName[remote.Name] = len(merged)
+			merged = append(merged, remote)
+		}
+	}
+
+	return merged
+}
+
+func mergeManifestProjects(parent []ManifestProject, child []ManifestProject) []ManifestProject {
+	merged := make([]ManifestProject, 0, len(parent)+len(child))
+	indexByName := make(map[string]int)
+
+	for _, project := range parent {
+		indexByName[project.Name] = len(merged)
+		merged = append(merged, project)
+	}
+
+	for _, project := range child {
+		if i, ok := indexByName[project.Name]; ok && project.Name != `` {
+			merged[i] = project
+		} else {
+			indexByName[project.Name] = len(merged)
+			merged = append(merged, project)
+		}
+	}
+
+	return merged
 }
 
 func InitializeManifest(manifestUri string) error {
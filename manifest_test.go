@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestManifest(t *testing.T, dir string, name string, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+
+	return path
+}
+
+func TestLoadManifestDiamondInclude(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestManifest(t, dir, `common.xml`, `<manifest>
+  <project name="common" path="common"/>
+</manifest>`)
+
+	writeTestManifest(t, dir, `b.xml`, `<manifest>
+  <include name="common.xml"/>
+  <project name="b" path="b"/>
+</manifest>`)
+
+	writeTestManifest(t, dir, `c.xml`, `<manifest>
+  <include name="common.xml"/>
+  <project name="c" path="c"/>
+</manifest>`)
+
+	root := writeTestManifest(t, dir, `root.xml`, `<manifest>
+  <include name="b.xml"/>
+  <include name="c.xml"/>
+  <project name="root" path="root"/>
+</manifest>`)
+
+	manifest, err := LoadManifest(root)
+
+	if err != nil {
+		t.Fatalf("expected diamond include to resolve cleanly, got: %v", err)
+	}
+
+	names := make(map[string]bool)
+
+	for _, project := range manifest.Projects {
+		names[project.Name] = true
+	}
+
+	for _, want := range []string{`common`, `b`, `c`, `root`} {
+		if !names[want] {
+			t.Errorf("expected merged manifest to contain project %q, got %+v", want, manifest.Projects)
+		}
+	}
+}
+
+func TestLoadManifestIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestManifest(t, dir, `a.xml`, `<manifest>
+  <include name="b.xml"/>
+</manifest>`)
+
+	a := writeTestManifest(t, dir, `b.xml`, `<manifest>
+  <include name="a.xml"/>
+</manifest>`)
+
+	_ = a
+
+	if _, err := LoadManifest(filepath.Join(dir, `a.xml`)); err == nil {
+		t.Fatal("expected an include cycle to be rejected with an error")
+	}
+}
+
+func TestMergeManifestProjectsChildOverridesParent(t *testing.T) {
+	parent := []ManifestProject{
+		{Name: `shared`, Path: `old/path`},
+		{Name: `parent-only`, Path: `parent`},
+	}
+
+	child := []ManifestProject{
+		{Name: `shared`, Path: `new/path`},
+		{Name: `child-only`, Path: `child`},
+	}
+
+	merged := mergeManifestProjects(parent, child)
+
+	byName := make(map[string]ManifestProject)
+
+	for _, project := range merged {
+		byName[project.Name] = project
+	}
+
+	if len(merged) != 3 {
+		t.Fatalf("expected 3 merged projects, got %d: %+v", len(merged), merged)
+	}
+
+	if got := byName[`shared`].Path; got != `new/path` {
+		t.Errorf("expected child's project to override parent's, got path %q", got)
+	}
+
+	if _, ok := byName[`parent-only`]; !ok {
+		t.Error("expected parent-only project to be carried forward")
+	}
+
+	if _, ok := byName[`child-only`]; !ok {
+		t.Error("expected child-only project to be added")
+	}
+}
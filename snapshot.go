@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+
+	"github.com/ghetzel/go-stockutil/fileutil"
+	"github.com/ghetzel/go-stockutil/log"
+)
+
+// SourceManifestEntry is one project's entry in the companion JSON source manifest produced
+// alongside a pinned snapshot XML.
+type SourceManifestEntry struct {
+	Name         string `json:"name"`
+	RemoteURL    string `json:"remote_url"`
+	Revision     string `json:"revision"`
+	RelativePath string `json:"relative_path"`
+}
+
+// Snapshot walks every project in the manifest's implicit "default" group (projects tagged
+// "notdefault" are left out) and resolves its current HEAD SHA, returning a
+// pinned Manifest (all project revisions set to concrete SHAs) and the equivalent source-manifest
+// entries, suitable for round-tripping through Restore.
+func (self *Manifest) Snapshot() (*Manifest, []SourceManifestEntry, error) {
+	pinned := &Manifest{
+		Remotes: self.Remotes,
+		Config:  self.Config,
+	}
+
+	var entries []SourceManifestEntry
+
+	for _, project := range self.GetProjects(nil, nil) {
+		sha, err := GitRevParse(project.Path, `HEAD`)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", project.Name, err)
+		}
+
+		pinnedProject := project
+		pinnedProject.Revision = sha
+		pinnedProject.Projects = nil
+		pinned.Projects = append(pinned.Projects, pinnedProject)
+
+		entries = append(entries, SourceManifestEntry{
+			Name:         project.Name,
+			RemoteURL:    project.Fetch,
+			Revision:     sha,
+			RelativePath: project.Path,
+		})
+	}
+
+	return pinned, entries, nil
+}
+
+// WriteSnapshot writes the pinned manifest to manifestFile as XML and the source-manifest
+// entries to sourceManifestFile as JSON.
+func WriteSnapshot(manifestFile string, sourceManifestFile string, manifest *Manifest, entries []SourceManifestEntry) error {
+	if out, err := xml.MarshalIndent(manifest, ``, `  `); err == nil {
+		if err := os.WriteFile(manifestFile, out, 0644); err != nil {
+			return fmt.Errorf("failed to write snapshot manifest: %v", err)
+		}
+	} else {
+		return fmt.Errorf("failed to marshal snapshot manifest: %v", err)
+	}
+
+	if out, err := json.MarshalIndent(entries, ``, `  `); err == nil {
+		if err := os.WriteFile(sourceManifestFile, out, 0644); err != nil {
+			return fmt.Errorf("failed to write source manifest: %v", err)
+		}
+	} else {
+		return fmt.Errorf("failed to marshal source manifest: %v", err)
+	}
+
+	return nil
+}
+
+// Restore detaches every project in a pinned snapshot manifest at its recorded revision,
+// cloning the project first if it isn't already present. Unlike Clone/Checkout, the pinned
+// revision is treated as authoritative and is never pulled, only checked out.
+//
+// This iterates self.Projects directly rather than going through GetProjects: Snapshot already
+// wrote each project's Fetch and Path fully resolved, so re-running them through GetProjects'
+// remote/parent fetch-joining would concatenate the remote prefix onto an already-complete URL.
+func (self *Manifest) Restore() error {
+	var merr error
+
+	for _, project := range self.Projects {
+		if !fileutil.DirExists(project.Path) {
+			if err := GitClone(project.Fetch, project.Path); err != nil {
+				merr = log.AppendError(merr, fmt.Errorf("%s: %v", project.Name, err))
+				continue
+			}
+		}
+
+		if err := GitCheckoutDetached(project.Path, project.Revision); err == nil {
+			log.Infof("Restored %s at %s", project.Name, project.Revision)
+		} else {
+			merr = log.AppendError(merr, fmt.Errorf("%s: failed to detach at %s: %v", project.Name, project.Revision, err))
+		}
+	}
+
+	return merr
+}